@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// configFileName is the optional config file read from the working
+// directory. Its presence is entirely optional: a missing file just means
+// "use the defaults".
+const configFileName = "config.json"
+
+// defaultPollInterval is used when PollIntervalMillis is unset, for
+// platforms whose watcher has to poll rather than subscribe to OS events.
+const defaultPollInterval = 500 * time.Millisecond
+
+// defaultDiscoveryPollInterval is used when DiscoveryPollIntervalMillis is
+// unset, governing how often the Supervisor rescans USB serial ports for
+// configured devices appearing or disappearing.
+const defaultDiscoveryPollInterval = 2 * time.Second
+
+// Config holds user-tunable runtime settings.
+type Config struct {
+	// TargetDevice is a case-insensitive substring match against the
+	// system's capture device names. Leave empty to use the default
+	// capture device (macOS only ever has one, so this is ignored there).
+	TargetDevice string `json:"targetDevice"`
+
+	// PollIntervalMillis is how often to poll for external mute-state
+	// changes on platforms with no event-based watcher (currently macOS and
+	// Windows). Defaults to defaultPollInterval when zero.
+	PollIntervalMillis int `json:"pollIntervalMillis"`
+
+	// Devices lists the USB serial devices the Supervisor should watch for
+	// by {vid, pid, role}. If empty, a single roleMaster device using
+	// targetArduinoVID/targetArduinoPID is assumed, matching the original
+	// single-board behavior.
+	Devices []DeviceConfig `json:"devices"`
+
+	// DiscoveryPollIntervalMillis is how often the Supervisor rescans USB
+	// serial ports for hot-plug changes. Defaults to
+	// defaultDiscoveryPollInterval when zero.
+	DiscoveryPollIntervalMillis int `json:"discoveryPollIntervalMillis"`
+
+	// Transports lists additional, non-serial device links to run the
+	// protocol over (e.g. a networked ESP32 button box). USB serial devices
+	// are never configured here; see Devices.
+	Transports []TransportConfig `json:"transports"`
+
+	// PTT configures push-to-talk handling for devices that declare the
+	// protocol.CapabilityPTT capability. Zero value means auto mode with the
+	// default hold threshold; see PTTConfig.
+	PTT PTTConfig `json:"ptt"`
+}
+
+// PTT modes accepted in PTTConfig.Mode.
+const (
+	// pttModeAuto picks momentary or toggle per press based on how long the
+	// button was held, per PTTConfig.HoldThresholdMillis. This is the
+	// default, since it lets one physical button cover both UX patterns.
+	pttModeAuto = "auto"
+	// pttModeMomentary unmutes while the button is held and mutes again on
+	// release, regardless of how long it was held.
+	pttModeMomentary = "momentary"
+	// pttModeToggle flips the mute state once per release, ignoring hold
+	// duration.
+	pttModeToggle = "toggle"
+	// pttModeCough temporarily mutes while the button is held (muting only
+	// if not already muted) and restores the prior state on release.
+	pttModeCough = "cough"
+)
+
+// defaultPTTHoldThreshold is used when HoldThresholdMillis is unset, in
+// pttModeAuto: presses held shorter than this are treated as a toggle
+// click, presses held at least this long are treated as momentary.
+const defaultPTTHoldThreshold = 300 * time.Millisecond
+
+// PTTConfig configures push-to-talk handling for capability-declaring
+// devices.
+type PTTConfig struct {
+	// Mode is one of pttModeAuto (the default when empty), pttModeMomentary,
+	// pttModeToggle, or pttModeCough.
+	Mode string `json:"mode"`
+
+	// HoldThresholdMillis is the press duration, in pttModeAuto, at or above
+	// which a press is treated as momentary rather than a toggle click.
+	// Defaults to defaultPTTHoldThreshold when zero.
+	HoldThresholdMillis int `json:"holdThresholdMillis"`
+
+	// ScopeProcess, if set, restricts muting to the named process's capture
+	// session (e.g. "zoom.exe", "Discord") instead of the whole capture
+	// device. Only works on Windows: the macOS MicController implements
+	// ScopedMicController but returns an error from every call (see
+	// mic_darwin.go), and the Linux one does not implement it.
+	ScopeProcess string `json:"scopeProcess,omitempty"`
+}
+
+// Roles a configured device can declare. Only roleMaster has dedicated
+// behavior today (unmuting the mic if it disconnects); other roles are free
+// text for the operator's own bookkeeping and are otherwise treated the
+// same by the protocol.
+const (
+	roleMaster = "master"
+)
+
+// DeviceConfig describes one USB serial device the Supervisor should watch
+// for and manage.
+type DeviceConfig struct {
+	// VID and PID identify the device, e.g. "0x2341"/"0x1002" for an Arduino
+	// Uno R4 WiFi. Matched case-insensitively against discoverPorts' output.
+	VID string `json:"vid"`
+	PID string `json:"pid"`
+
+	// Role describes what this device does (e.g. roleMaster for the mute
+	// button, "indicator" for an LED-only status device, "mixer" for a
+	// volume control surface). Used by the Supervisor to decide whether
+	// losing the device should unmute the microphone. The role comes from
+	// this config only: it is neither sent to nor read from the device
+	// during the HELLO handshake.
+	Role string `json:"role"`
+}
+
+// Transport type names accepted in TransportConfig.Type.
+const (
+	transportTypeTCPDial   = "tcp-dial"
+	transportTypeTCPListen = "tcp-listen"
+	transportTypeWSListen  = "ws-listen"
+)
+
+// TransportConfig describes one non-serial device link to run the protocol
+// over. Exactly the fields relevant to Type are used; the rest are ignored.
+type TransportConfig struct {
+	// Type is one of transportTypeTCPDial, transportTypeTCPListen, or
+	// transportTypeWSListen.
+	Type string `json:"type"`
+
+	// Address is the host:port used by "tcp-dial", "tcp-listen", and
+	// "ws-listen" transports.
+	Address string `json:"address,omitempty"`
+
+	// Path is the HTTP path a "ws-listen" transport upgrades, defaulting to
+	// "/".
+	Path string `json:"path,omitempty"`
+}
+
+// PollInterval returns the configured poll interval, or defaultPollInterval
+// if none was set.
+func (c Config) PollInterval() time.Duration {
+	if c.PollIntervalMillis <= 0 {
+		return defaultPollInterval
+	}
+	return time.Duration(c.PollIntervalMillis) * time.Millisecond
+}
+
+// DiscoveryPollInterval returns the configured USB discovery poll interval,
+// or defaultDiscoveryPollInterval if none was set.
+func (c Config) DiscoveryPollInterval() time.Duration {
+	if c.DiscoveryPollIntervalMillis <= 0 {
+		return defaultDiscoveryPollInterval
+	}
+	return time.Duration(c.DiscoveryPollIntervalMillis) * time.Millisecond
+}
+
+// PTTMode returns the configured PTT mode, or pttModeAuto if none was set.
+func (p PTTConfig) PTTMode() string {
+	if p.Mode == "" {
+		return pttModeAuto
+	}
+	return p.Mode
+}
+
+// HoldThreshold returns the configured hold threshold, or
+// defaultPTTHoldThreshold if none was set.
+func (p PTTConfig) HoldThreshold() time.Duration {
+	if p.HoldThresholdMillis <= 0 {
+		return defaultPTTHoldThreshold
+	}
+	return time.Duration(p.HoldThresholdMillis) * time.Millisecond
+}
+
+// loadConfig reads configFileName from the current directory.
+func loadConfig() (Config, error) {
+	data, err := os.ReadFile(configFileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Config{}, nil
+		}
+		return Config{}, fmt.Errorf("error reading %s: %v", configFileName, err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("error parsing %s: %v", configFileName, err)
+	}
+	return cfg, nil
+}
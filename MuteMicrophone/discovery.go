@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"go.bug.st/serial/enumerator"
+)
+
+// discoveredPort describes one USB serial port currently present on the
+// system.
+type discoveredPort struct {
+	Address string
+	VID     string
+	PID     string
+}
+
+// discoverPorts lists the USB serial ports currently attached, using the
+// enumerator package directly rather than shelling out to arduino-cli, since
+// the Supervisor needs to call this cheaply on every poll tick.
+func discoverPorts() ([]discoveredPort, error) {
+	ports, err := enumerator.GetDetailedPortsList()
+	if err != nil {
+		return nil, fmt.Errorf("error listing serial ports: %v", err)
+	}
+
+	var result []discoveredPort
+	for _, p := range ports {
+		if !p.IsUSB {
+			continue
+		}
+		result = append(result, discoveredPort{
+			Address: p.Name,
+			VID:     normalizeHexID(p.VID),
+			PID:     normalizeHexID(p.PID),
+		})
+	}
+	return result, nil
+}
+
+// normalizeHexID lowercases id and ensures it has a "0x" prefix, so VID/PID
+// values from the enumerator and from config files compare equal regardless
+// of how each happens to be formatted.
+func normalizeHexID(id string) string {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if id == "" {
+		return ""
+	}
+	if !strings.HasPrefix(id, "0x") {
+		id = "0x" + id
+	}
+	return id
+}
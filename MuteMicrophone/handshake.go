@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lukdog/microphone-remote/protocol"
+)
+
+// handshakeMode describes which wire format a device session uses.
+type handshakeMode int
+
+const (
+	modeLegacy handshakeMode = iota // plain MUTE/UNMUTE/GET_STATE/MUTED/UNMUTED/ERROR lines
+	modeJSON                        // newline-delimited JSON per the protocol package
+)
+
+// deviceSession is an established connection to a device after the
+// handshake, abstracting away whether it speaks the JSON protocol or the
+// legacy plain-text one so the rest of main can stay protocol-agnostic.
+type deviceSession struct {
+	mode              handshakeMode
+	supportsStatePush bool
+	supportsPTT       bool
+
+	conn  Transport
+	lines *lineReader
+	enc   *protocol.Encoder
+}
+
+// lineReader owns the single goroutine that reads newline-delimited lines
+// off a connection. The HELLO handshake attempt, the legacy IDENTIFY_ARDUINO
+// handshake attempt, and the post-handshake command loop all read through
+// the same lineReader instead of each running their own ReadString loop, so
+// nothing ever races for bytes on the underlying bufio.Reader.
+type lineReader struct {
+	lines chan string
+	errs  chan error
+}
+
+// newLineReader starts the read loop over r.
+func newLineReader(r io.Reader) *lineReader {
+	lr := &lineReader{
+		lines: make(chan string),
+		errs:  make(chan error, 1),
+	}
+	go func() {
+		reader := bufio.NewReader(r)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				lr.errs <- err
+				return
+			}
+			lr.lines <- line
+		}
+	}()
+	return lr
+}
+
+// readLine waits for the next line, or up to timeout if timeout is nonzero.
+func (lr *lineReader) readLine(timeout time.Duration) (string, error) {
+	var after <-chan time.Time
+	if timeout > 0 {
+		after = time.After(timeout)
+	}
+	select {
+	case line := <-lr.lines:
+		return line, nil
+	case err := <-lr.errs:
+		return "", err
+	case <-after:
+		return "", fmt.Errorf("timeout waiting for response after %s", timeout)
+	}
+}
+
+// connectDevice runs the handshake against conn: it first tries the HELLO/
+// JSON handshake and, if the device doesn't answer it, falls back to the
+// legacy IDENTIFY_ARDUINO/IDENTIFY_ACK exchange for older sketches.
+func connectDevice(conn Transport) (*deviceSession, error) {
+	lines := newLineReader(conn)
+
+	session, jsonErr := attemptJSONHandshake(conn, lines)
+	if jsonErr == nil {
+		return session, nil
+	}
+	log.Printf("JSON handshake failed: %v. Falling back to legacy IDENTIFY_ARDUINO handshake.", jsonErr)
+
+	return attemptLegacyHandshake(conn, lines)
+}
+
+// attemptJSONHandshake sends `HELLO <version> "<name>"` and waits for a JSON
+// Hello reply within identificationTimeout.
+func attemptJSONHandshake(conn Transport, lines *lineReader) (*deviceSession, error) {
+	log.Println("Attempting handshake by sending HELLO...")
+
+	helloLine := fmt.Sprintf("HELLO %d %q\n", protocol.HandshakeVersion, protocol.HandshakeName)
+	if _, err := conn.Write([]byte(helloLine)); err != nil {
+		return nil, fmt.Errorf("error sending HELLO: %v", err)
+	}
+
+	line, err := lines.readLine(identificationTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("error reading HELLO response: %v", err)
+	}
+
+	var hello protocol.Hello
+	if err := json.Unmarshal([]byte(strings.TrimSpace(line)), &hello); err != nil {
+		return nil, fmt.Errorf("HELLO response is not valid JSON: %v", err)
+	}
+	if hello.EventType != protocol.EventTypeHello {
+		return nil, fmt.Errorf("unexpected eventType %q in HELLO response", hello.EventType)
+	}
+
+	log.Printf("Device handshake succeeded: protocolVersion=%d firmware=%q capabilities=%v", hello.ProtocolVersion, hello.Firmware, hello.Capabilities)
+	return &deviceSession{
+		mode:              modeJSON,
+		supportsStatePush: hello.SupportsStatePush(),
+		supportsPTT:       hello.SupportsPTT(),
+		conn:              conn,
+		lines:             lines,
+		enc:               protocol.NewEncoder(conn),
+	}, nil
+}
+
+// attemptLegacyHandshake sends IDENTIFY_ARDUINO and expects IDENTIFY_ACK, for
+// sketches written before the HELLO handshake existed.
+func attemptLegacyHandshake(conn Transport, lines *lineReader) (*deviceSession, error) {
+	log.Println("Attempting to identify Arduino by sending IDENTIFY_ARDUINO command...")
+
+	if _, err := conn.Write([]byte(cmdIdentify + "\n")); err != nil {
+		return nil, fmt.Errorf("error sending IDENTIFY_ARDUINO for identification: %v", err)
+	}
+
+	line, err := lines.readLine(identificationTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response for identification: %v", err)
+	}
+
+	response := strings.TrimSpace(strings.ToUpper(line))
+	if response != respIdentifyAck {
+		return nil, fmt.Errorf("received unexpected response for identification: '%s'", response)
+	}
+	log.Println("Arduino successfully identified with IDENTIFY_ACK; using legacy plain-text protocol.")
+	return &deviceSession{mode: modeLegacy, conn: conn, lines: lines}, nil
+}
+
+// readCommand blocks for the next command from the device, normalized to one
+// of cmdMute, cmdUnmute, cmdGetState, cmdPress or cmdRelease. An empty
+// string means the command wasn't recognized.
+func (s *deviceSession) readCommand() (string, error) {
+	line, err := s.lines.readLine(0)
+	if err != nil {
+		return "", err
+	}
+
+	if s.mode == modeLegacy {
+		switch strings.TrimSpace(strings.ToUpper(line)) {
+		case cmdMute:
+			return cmdMute, nil
+		case cmdUnmute:
+			return cmdUnmute, nil
+		case cmdGetState:
+			return cmdGetState, nil
+		default:
+			return "", nil
+		}
+	}
+
+	var cmd protocol.Command
+	if err := json.Unmarshal([]byte(strings.TrimSpace(line)), &cmd); err != nil {
+		return "", fmt.Errorf("protocol: error unmarshaling message %q: %v", strings.TrimSpace(line), err)
+	}
+	switch cmd.Cmd {
+	case protocol.CmdSet:
+		if cmd.Muted {
+			return cmdMute, nil
+		}
+		return cmdUnmute, nil
+	case protocol.CmdGet:
+		return cmdGetState, nil
+	case protocol.CmdPress:
+		return cmdPress, nil
+	case protocol.CmdRelease:
+		return cmdRelease, nil
+	default:
+		return "", nil
+	}
+}
+
+// writeState sends the current mute state to the device, either as a plain
+// MUTED/UNMUTED line (legacy) or a {"event":"state",...} message (JSON).
+func (s *deviceSession) writeState(muted bool) error {
+	if s.mode == modeLegacy {
+		resp := respUnmuted
+		if muted {
+			resp = respMuted
+		}
+		_, err := s.conn.Write([]byte(resp + "\n"))
+		return err
+	}
+	return s.enc.Encode(protocol.Event{Event: protocol.EventState, Muted: muted})
+}
+
+// writeError sends an error to the device. code is only surfaced in JSON
+// mode; the legacy protocol only distinguishes "unknown command" from any
+// other error.
+func (s *deviceSession) writeError(code string) error {
+	if s.mode == modeLegacy {
+		resp := respError
+		if code == protocol.ErrorCodeUnknownCmd {
+			resp = respUnknown
+		}
+		_, err := s.conn.Write([]byte(resp + "\n"))
+		return err
+	}
+	return s.enc.Encode(protocol.Event{Event: protocol.EventError, Code: code})
+}
+
+// canPushState reports whether unsolicited state pushes should be sent to
+// this device. Legacy devices always get them (the original behavior);
+// JSON devices only get them if the handshake declared support, which in
+// turn requires protocolVersion >= protocol.MinStatePushVersion.
+func (s *deviceSession) canPushState() bool {
+	return s.mode == modeLegacy || s.supportsStatePush
+}
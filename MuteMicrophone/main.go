@@ -1,15 +1,12 @@
 package main
 
 import (
-	"bufio"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os/exec"
-	"strings"
+	"sync"
 	"time"
 
-	"go.bug.st/serial" // Imports the serial communication library
+	"github.com/lukdog/microphone-remote/protocol"
 )
 
 // Defines serial communication commands and responses
@@ -17,6 +14,8 @@ const (
 	cmdMute         = "MUTE"
 	cmdUnmute       = "UNMUTE"
 	cmdGetState     = "GET_STATE"
+	cmdPress        = "PRESS"   // push-to-talk button down (JSON protocol only)
+	cmdRelease      = "RELEASE" // push-to-talk button up (JSON protocol only)
 	cmdIdentify     = "IDENTIFY_ARDUINO"
 	respMuted       = "MUTED"
 	respUnmuted     = "UNMUTED"
@@ -26,373 +25,233 @@ const (
 
 	serialBaud = 9600
 
-	// Identifiers for your specific Arduino.
-	// You can find these values by running 'arduino-cli board list --format json'
-	// in your terminal when your Arduino is connected.
-	// Example for Arduino Uno R4 WiFi from your output: VID = "0x2341", PID = "0x1002"
-	// Ensure these match the output you see.
+	// Default VID/PID used when no "devices" entries are configured, so a
+	// single Arduino still works out of the box. You can find these values
+	// by running 'arduino-cli board list --format json' with your Arduino
+	// connected.
+	// Example for Arduino Uno R4 WiFi: VID = "0x2341", PID = "0x1002"
 	targetArduinoVID = "0x2341" // <--- CHANGE THIS TO YOUR ARDUINO'S VID
 	targetArduinoPID = "0x1002" // <--- CHANGE THIS TO YOUR ARDUINO'S PID
 
 	identificationTimeout = 3 * time.Second // Timeout for identification response
 )
 
-// PortProperties contains detailed properties of the serial port, including VID and PID.
-type PortProperties struct {
-	PID          string `json:"pid"`
-	SerialNumber string `json:"serialNumber"`
-	VID          string `json:"vid"`
-}
-
-// Port represents the serial port information.
-type Port struct {
-	Address       string         `json:"address"`
-	Label         string         `json:"label"`
-	Protocol      string         `json:"protocol"`
-	ProtocolLabel string         `json:"protocol_label"`
-	Properties    PortProperties `json:"properties"`
-	HardwareID    string         `json:"hardware_id"`
-}
-
-// MatchingBoard represents information about a board matching the port.
-type MatchingBoard struct {
-	Name string `json:"name"`
-	Fqbn string `json:"fqbn"`
-}
-
-// DetectedPortItem represents an item within the "detected_ports" array.
-// It might contain matching_boards or just port info.
-type DetectedPortItem struct {
-	MatchingBoards []MatchingBoard `json:"matching_boards"` // Optional, only for some ports (can be empty)
-	Port           Port            `json:"port"`
-}
-
-// ArduinoCLIResponse is the top-level structure for the entire JSON output from arduino-cli.
-type ArduinoCLIResponse struct {
-	DetectedPorts []DetectedPortItem `json:"detected_ports"`
-}
-
-// setMicrophoneMuteState sets the mute state of the system microphone on macOS.
-// It uses osascript to interact with audio settings.
-func setMicrophoneMuteState(mute bool) error {
-	var script string
-	if mute {
-		script = `set volume input volume 0` // Mute the microphone
-	} else {
-		script = `set volume input volume 100` // Unmute the microphone (sets to 100%, can be adjusted)
-	}
+func main() {
+	log.Println("Go application for microphone control started.")
 
-	cmd := exec.Command("osascript", "-e", script)
-	output, err := cmd.CombinedOutput()
+	cfg, err := loadConfig()
 	if err != nil {
-		return fmt.Errorf("error executing osascript to set state: %v\nOutput: %s", err, output)
+		log.Fatalf("Error loading config: %v", err)
 	}
-	log.Printf("Microphone set to Mute: %t. osascript output: %s", mute, strings.TrimSpace(string(output)))
-	return nil
-}
 
-// getMicrophoneMuteState retrieves the mute state of the system microphone on macOS.
-// It uses osascript to query the audio state.
-func getMicrophoneMuteState() (bool, error) {
-	// AppleScript to get input volume.
-	// If input volume is 0, the microphone is considered muted.
-	script := `get volume settings`
-
-	cmd := exec.Command("osascript", "-e", script)
-	output, err := cmd.CombinedOutput()
+	mic, err := newMicController(cfg.TargetDevice)
 	if err != nil {
-		return false, fmt.Errorf("error executing osascript to get state: %v\nOutput: %s", err, output)
+		log.Fatalf("Error initializing microphone controller: %v", err)
 	}
 
-	// The output will be something like "output volume:40, input volume:100, alert volume:100, output muted:false, input muted:false"
-	// We need to parse the string to find "input volume:" and its value.
-	outputStr := strings.TrimSpace(string(output))
-	log.Printf("osascript output for get state: %s", outputStr)
+	var wg sync.WaitGroup
 
-	// Search for the string "input volume:"
-	inputVolumeIndex := strings.Index(outputStr, "input volume:")
-	if inputVolumeIndex == -1 {
-		return false, fmt.Errorf("cannot find 'input volume' in osascript output")
-	}
+	// The supervisor owns every USB serial device: it watches for ports
+	// matching the configured {vid, pid, role} entries appearing and
+	// disappearing, and starts/stops a handler per device accordingly.
+	// There's no more single hardcoded port to connect to.
+	supervisor := newSupervisor(mic, cfg)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		supervisor.Run()
+	}()
 
-	// Extract the substring after "input volume:"
-	sub := outputStr[inputVolumeIndex+len("input volume:"):]
-	// Find the end of the input volume number
-	endIndex := strings.IndexAny(sub, ", \n") // Search for comma, space or newline
-	if endIndex == -1 {
-		endIndex = len(sub) // If no delimiters, the rest is the number
-	}
-	volumeStr := strings.TrimSpace(sub[:endIndex])
+	// Any additional non-serial links (TCP, WebSocket) are configured
+	// explicitly, since they can't be discovered by USB hot-plugging.
+	for i, tc := range cfg.Transports {
+		label := fmt.Sprintf("%s#%d", tc.Type, i)
+		open, err := newTransportOpener(tc)
+		if err != nil {
+			log.Fatalf("Error configuring transport %s: %v", label, err)
+		}
 
-	var volume int
-	_, err = fmt.Sscanf(volumeStr, "%d", &volume)
-	if err != nil {
-		return false, fmt.Errorf("cannot parse input volume '%s': %v", volumeStr, err)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runTransport(label, open, mic, cfg)
+		}()
 	}
-
-	// If input volume is 0, we consider the microphone muted.
-	isMuted := (volume == 0)
-	return isMuted, nil
+	wg.Wait()
 }
 
-// findSpecificArduinoPort executes arduino-cli to find the port of a specific Arduino board
-func findSpecificArduinoPort(targetVID, targetPID string) (string, error) {
-	log.Println("Searching for specific Arduino port using arduino-cli...")
-	cmd := exec.Command("arduino-cli", "board", "list", "--format", "json")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("error executing 'arduino-cli board list': %v\nOutput: %s\nPlease ensure arduino-cli is installed and configured", err, output)
-	}
-
-	// Unmarshal the JSON output into the top-level ArduinoCLIResponse struct
-	var cliResponse ArduinoCLIResponse
-	err = json.Unmarshal(output, &cliResponse)
-	if err != nil {
-		return "", fmt.Errorf("error parsing arduino-cli JSON output: %v\nOutput: %s", err, output)
-	}
+// runTransport repeatedly acquires a Transport via open and runs the device
+// protocol over it for as long as the connection stays up, handling
+// reconnects the same way regardless of which kind of transport this is.
+func runTransport(label string, open func() (Transport, error), mic MicController, cfg Config) {
+	for {
+		log.Printf("[%s] Waiting for device...", label)
+		transport, err := open()
+		if err != nil {
+			log.Printf("[%s] Error acquiring transport: %v. Retrying in 5 seconds...", label, err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		log.Printf("[%s] Transport connected: %s", label, transport.Name())
 
-	log.Printf("Detected %d ports by arduino-cli...", len(cliResponse.DetectedPorts))
+		runDeviceSession(label, transport, mic, cfg)
 
-	for _, item := range cliResponse.DetectedPorts {
-		// Check if the current item has port properties (not all port types will, e.g., debug-console)
-		// and if it matches the target VID/PID
-		if item.Port.Properties.VID == targetVID && item.Port.Properties.PID == targetPID {
-			// You can optionally log the matching board name from MatchingBoards if available
-			boardName := "Unknown Board"
-			if len(item.MatchingBoards) > 0 {
-				boardName = item.MatchingBoards[0].Name
-			}
-			log.Printf("Found potential Arduino board: %s (VID:%s, PID:%s) on port: %s", boardName, item.Port.Properties.VID, item.Port.Properties.PID, item.Port.Address)
-			return item.Port.Address, nil
-		}
+		// The session above only returns once the connection has dropped;
+		// wait a moment before trying to reacquire the transport.
+		time.Sleep(1 * time.Second)
 	}
-
-	return "", fmt.Errorf("no Arduino board found with VID: %s and PID: %s. Please ensure it is connected and 'arduino-cli' can detect it", targetVID, targetPID)
 }
 
-// identifyArduino sends CMD_IDENTIFY to the Arduino and waits for RESP_IDENTIFY_ACK within a timeout.
-// This confirms the connected board is running the expected sketch.
-func identifyArduino(port serial.Port) error {
-	log.Println("Attempting to identify Arduino by sending IDENTIFY_ARDUINO command...")
-
-	// Create a new reader for this specific identification attempt
-	reader := bufio.NewReader(port)
-
-	// Send the IDENTIFY_ARDUINO command
-	_, err := port.Write([]byte(cmdIdentify + "\n"))
+// runDeviceSession handshakes over transport, synchronizes the initial mic
+// state, and then services commands and pushes unsolicited state changes
+// until the connection drops. The mic is unmuted when the connection drops
+// or a write to the device fails, since nobody is left to un-mute it.
+//
+// Each session gets its own echoSuppressor: a change one device's session
+// makes must never be swallowed by a different device's session racing to
+// observe it first on its own MicWatcher poll.
+func runDeviceSession(label string, transport Transport, mic MicController, cfg Config) {
+	defer transport.Close()
+
+	echo := &echoSuppressor{}
+
+	// Handshake with the device: try the HELLO/JSON protocol first, falling
+	// back to the legacy IDENTIFY_ARDUINO exchange.
+	session, err := connectDevice(transport)
 	if err != nil {
-		return fmt.Errorf("error sending IDENTIFY_ARDUINO for identification: %v", err)
+		log.Printf("[%s] Device handshake failed: %v", label, err)
+		return
 	}
 
-	responseChan := make(chan string, 1)
-	errChan := make(chan error, 1)
-
-	// Goroutine to read the response to prevent blocking the main loop
-	go func() {
-		line, readErr := reader.ReadString('\n')
-		if readErr != nil {
-			errChan <- fmt.Errorf("error reading response for identification: %v", readErr)
+	// Now that the handshake succeeded, get the actual microphone state
+	// from the host OS and send it to the device to synchronize its LED.
+	if currentSystemMuteState, err := mic.Muted(); err != nil {
+		log.Printf("[%s] Error retrieving current system microphone state for initial sync: %v. Proceeding without initial sync.", label, err)
+	} else {
+		if writeErr := session.writeState(currentSystemMuteState); writeErr != nil {
+			log.Printf("[%s] Error sending initial system microphone state: %v", label, writeErr)
 			return
 		}
-		responseChan <- strings.TrimSpace(strings.ToUpper(line))
-	}()
-
-	select {
-	case response := <-responseChan:
-		if response == respIdentifyAck {
-			log.Println("Arduino successfully identified with IDENTIFY_ACK.")
-			return nil
-		}
-		return fmt.Errorf("received unexpected response for identification: '%s'", response)
-	case <-time.After(identificationTimeout):
-		return fmt.Errorf("timeout waiting for Arduino identification (IDENTIFY_ACK) response after %s", identificationTimeout)
+		log.Printf("[%s] Initial system microphone state sent: muted=%t", label, currentSystemMuteState)
 	}
-}
 
-func main() {
-	log.Println("Go application for microphone control started.")
-
-	// Outer loop to handle serial port reconnection
-	for {
-		log.Println("Attempting to connect to Arduino...")
-		portName, err := findSpecificArduinoPort(targetArduinoVID, targetArduinoPID)
-		if err != nil {
-			log.Printf("Unable to find Arduino port with matching VID/PID: %v. Retrying in 5 seconds...", err)
-			time.Sleep(5 * time.Second) // Wait before retrying search
-			continue                    // Go back to the beginning of the outer loop to retry
-		}
-
-		// Serial port configuration mode
-		mode := &serial.Mode{
-			BaudRate: serialBaud,
-			Parity:   serial.NoParity,
-			DataBits: 8,
-			StopBits: serial.OneStopBit,
+	// lineChan/readErrChan let the loop below select between incoming
+	// commands and unsolicited watcher notifications instead of blocking
+	// solely on readCommand.
+	lineChan := make(chan string)
+	readErrChan := make(chan error, 1)
+	go func() {
+		for {
+			command, err := session.readCommand()
+			if err != nil {
+				readErrChan <- err
+				return
+			}
+			lineChan <- command
 		}
+	}()
 
-		log.Printf("Attempting to open serial port: %s at %d baud...", portName, serialBaud)
-		port, err := serial.Open(portName, mode)
-		if err != nil {
-			log.Printf("Error opening serial port %s: %v. Retrying in 5 seconds...", portName, err)
-			time.Sleep(5 * time.Second) // Wait before retrying open
-			continue                    // Go back to the beginning of the outer loop to retry
-		}
-		log.Println("Serial port opened successfully.")
+	ptt := newPTTController(mic, echo, cfg)
 
-		// Try to identify the Arduino by sending a specific command and waiting for acknowledgment.
-		err = identifyArduino(port)
-		if err != nil {
-			log.Printf("Arduino identification failed: %v. Closing port and retrying...", err)
-			port.Close()                // Close the port if identification fails
-			time.Sleep(1 * time.Second) // Small delay before next retry
-			continue                    // Go back to the outer loop to find another port
-		}
+	watcher, err := newMicWatcher(mic, cfg.PollInterval())
+	if err != nil {
+		log.Printf("[%s] Error starting microphone state watcher: %v. Continuing without unsolicited state updates.", label, err)
+	}
+	var watcherChanges <-chan bool
+	if watcher != nil {
+		defer watcher.Close()
+		watcherChanges = watcher.Changes()
+	}
 
-		// If identification successful, get the actual microphone state from macOS
-		// and send it to the Arduino to synchronize its LED.
-		currentSystemMuteState, err := getMicrophoneMuteState()
-		if err != nil {
-			log.Printf("Error retrieving current system microphone state for initial sync: %v. Proceeding without initial sync.", err)
-			// Continue even if initial sync fails, but log the error
-		} else {
-			var initialSyncResp string
-			if currentSystemMuteState {
-				initialSyncResp = respMuted
+	// This loop continues as long as the connection is stable
+	for {
+		select {
+		case err := <-readErrChan:
+			log.Printf("[%s] Error reading from transport (device likely disconnected): %v. Attempting to unmute microphone...", label, err)
+			if unmuteErr := setMicMuted(mic, echo, false); unmuteErr != nil {
+				log.Printf("[%s] Error unmuting microphone after disconnection: %v", label, unmuteErr)
 			} else {
-				initialSyncResp = respUnmuted
-			}
-			_, writeErr := port.Write([]byte(initialSyncResp + "\n"))
-			if writeErr != nil {
-				log.Printf("Error sending initial system microphone state to Arduino via serial: %v. Closing port and retrying...", writeErr)
-				port.Close()
-				continue // Force the outer loop to retry
+				log.Printf("[%s] Microphone unmuted due to disconnection.", label)
 			}
-			log.Printf("Initial system microphone state sent to Arduino: %s", initialSyncResp)
-		}
-
-		reader := bufio.NewReader(port) // Re-initialize reader for the main loop
+			return
 
-		// This loop continues as long as the connection is stable
-	inner:
-		for {
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				log.Printf("Error reading from serial port (device likely disconnected): %v. Attempting to unmute microphone...", err)
-				// Attempt to unmute the microphone
-				unmuteErr := setMicrophoneMuteState(false)
-				if unmuteErr != nil {
-					log.Printf("Error unmuting microphone after board disconnection: %v", unmuteErr)
-				} else {
-					log.Println("Microphone unmuted due to board disconnection.")
-				}
-				port.Close() // Close the current (now erroneous) port
-				break inner  // Exit the inner loop to re-enter the outer loop and attempt reconnection
+		case muted := <-watcherChanges:
+			if echo.isEcho(muted) || !session.canPushState() {
+				continue
+			}
+			if writeErr := session.writeState(muted); writeErr != nil {
+				log.Printf("[%s] Error pushing unsolicited state change: %v", label, writeErr)
+				return
 			}
+			log.Printf("[%s] Pushed unsolicited state change: muted=%t", label, muted)
 
-			command := strings.TrimSpace(strings.ToUpper(line))
-			log.Printf("Command received from serial: '%s'", command)
+		case command := <-lineChan:
+			log.Printf("[%s] Command received: '%s'", label, command)
 
-			var response string
-			var newState bool // true for muted, false for unmuted
+			var writeErr error
 
 			switch command {
 			case cmdMute:
-				err = setMicrophoneMuteState(true)
-				if err != nil {
-					response = respError
-					log.Printf("Error muting microphone: %v", err)
+				if err := setMicMuted(mic, echo, true); err != nil {
+					log.Printf("[%s] Error muting microphone: %v", label, err)
+					writeErr = session.writeError(protocol.ErrorCodeMuteFailed)
 				} else {
-					response = respMuted
-					log.Println("Microphone muted successfully.")
-				}
-				// Send the response only if writing does not immediately generate an error
-				_, writeErr := port.Write([]byte(response + "\n"))
-				if writeErr != nil {
-					log.Printf("Error sending response via serial: %v", writeErr)
-					// Attempt to unmute the microphone before closing the port
-					unmuteErr := setMicrophoneMuteState(false)
-					if unmuteErr != nil {
-						log.Printf("Error unmuting microphone after serial write failure: %v", unmuteErr)
-					} else {
-						log.Println("Microphone unmuted due to serial write failure.")
-					}
-					port.Close() // Close and force reconnection
-					break inner
+					log.Printf("[%s] Microphone muted successfully.", label)
+					writeErr = session.writeState(true)
 				}
 
 			case cmdUnmute:
-				err = setMicrophoneMuteState(false)
-				if err != nil {
-					response = respError
-					log.Printf("Error unmuting microphone: %v", err)
+				if err := setMicMuted(mic, echo, false); err != nil {
+					log.Printf("[%s] Error unmuting microphone: %v", label, err)
+					writeErr = session.writeError(protocol.ErrorCodeUnmuteFailed)
 				} else {
-					response = respUnmuted
-					log.Println("Microphone unmuted successfully.")
-				}
-				_, writeErr := port.Write([]byte(response + "\n"))
-				if writeErr != nil {
-					log.Printf("Error sending response via serial: %v", writeErr)
-					// Attempt to unmute the microphone before closing the port
-					unmuteErr := setMicrophoneMuteState(false)
-					if unmuteErr != nil {
-						log.Printf("Error unmuting microphone after serial write failure: %v", unmuteErr)
-					} else {
-						log.Println("Microphone unmuted due to serial write failure.")
-					}
-					port.Close()
-					break inner
+					log.Printf("[%s] Microphone unmuted successfully.", label)
+					writeErr = session.writeState(false)
 				}
 
 			case cmdGetState:
-				// Arduino is asking for the current state.
-				newState, err = getMicrophoneMuteState()
-				if err != nil {
-					response = respError
-					log.Printf("Error retrieving microphone state for Arduino's GET_STATE request: %v", err)
+				// Device is asking for the current state.
+				if muted, err := mic.Muted(); err != nil {
+					log.Printf("[%s] Error retrieving microphone state for GET_STATE request: %v", label, err)
+					writeErr = session.writeError(protocol.ErrorCodeStateFailed)
 				} else {
-					if newState {
-						response = respMuted
-					} else {
-						response = respUnmuted
-					}
-					log.Printf("Responding to Arduino's GET_STATE request with: %s (actual macOS state).", response)
+					log.Printf("[%s] Responding to GET_STATE request with actual system state: muted=%t", label, muted)
+					writeErr = session.writeState(muted)
 				}
-				_, writeErr := port.Write([]byte(response + "\n"))
-				if writeErr != nil {
-					log.Printf("Error sending response to Arduino's GET_STATE request via serial: %v", writeErr)
-					// Attempt to unmute the microphone before closing the port
-					unmuteErr := setMicrophoneMuteState(false)
-					if unmuteErr != nil {
-						log.Printf("Error unmuting microphone after serial write failure: %v", unmuteErr)
-					} else {
-						log.Println("Microphone unmuted due to serial write failure.")
-					}
-					port.Close()
-					break inner
+
+			case cmdPress, cmdRelease:
+				if !session.supportsPTT {
+					writeErr = session.writeError(protocol.ErrorCodeUnknownCmd)
+					break
+				}
+				if command == cmdPress {
+					ptt.Press(label)
+				} else {
+					ptt.Release(label)
+				}
+				if muted, err := mic.Muted(); err != nil {
+					log.Printf("[%s] Error retrieving microphone state after PTT edge: %v", label, err)
+					writeErr = session.writeError(protocol.ErrorCodeStateFailed)
+				} else {
+					writeErr = session.writeState(muted)
 				}
 
 			default:
-				response = respUnknown
-				log.Printf("Unknown command received: '%s'", command)
-				_, writeErr := port.Write([]byte(response + "\n"))
-				if writeErr != nil {
-					log.Printf("Error sending response via serial: %v", writeErr)
-					// Attempt to unmute the microphone before closing the port
-					unmuteErr := setMicrophoneMuteState(false)
-					if unmuteErr != nil {
-						log.Printf("Error unmuting microphone after serial write failure: %v", unmuteErr)
-					} else {
-						log.Println("Microphone unmuted due to serial write failure.")
-					}
-					port.Close()
-					break inner
+				log.Printf("[%s] Unknown command received: '%s'", label, command)
+				writeErr = session.writeError(protocol.ErrorCodeUnknownCmd)
+			}
+
+			if writeErr != nil {
+				log.Printf("[%s] Error sending response: %v", label, writeErr)
+				if unmuteErr := setMicMuted(mic, echo, false); unmuteErr != nil {
+					log.Printf("[%s] Error unmuting microphone after write failure: %v", label, unmuteErr)
+				} else {
+					log.Printf("[%s] Microphone unmuted due to write failure.", label)
 				}
+				return
 			}
 
 			time.Sleep(50 * time.Millisecond)
 		}
-
-		// If the inner loop breaks, wait a moment before trying to reconnect
-		time.Sleep(1 * time.Second)
 	}
 }
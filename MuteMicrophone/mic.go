@@ -0,0 +1,12 @@
+package main
+
+// MicController abstracts platform-specific control of the system's
+// microphone (capture device) mute state. Each supported OS provides its own
+// implementation in a mic_<os>.go file, selected at compile time via build
+// tags, and is constructed through newMicController.
+type MicController interface {
+	// SetMuted mutes or unmutes the target capture device.
+	SetMuted(muted bool) error
+	// Muted reports the current mute state of the target capture device.
+	Muted() (bool, error)
+}
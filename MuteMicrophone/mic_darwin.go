@@ -0,0 +1,85 @@
+//go:build darwin
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+)
+
+// darwinMicController controls the system microphone via osascript.
+// AppleScript has no way to set "input muted" directly, so muting still
+// drives input volume to 0 (restoring 100 on unmute); reading the state,
+// however, parses the real "input muted" flag instead of inferring it from
+// the volume, since macOS only ever exposes one capture device there is
+// nothing for targetDevice to select and it is accepted only for interface
+// parity with the other platforms.
+type darwinMicController struct {
+	targetDevice string
+}
+
+func newMicController(targetDevice string) (MicController, error) {
+	if targetDevice != "" {
+		log.Printf("targetDevice %q requested but macOS only exposes the default input device; ignoring", targetDevice)
+	}
+	return &darwinMicController{targetDevice: targetDevice}, nil
+}
+
+func (m *darwinMicController) SetMuted(muted bool) error {
+	volume := 100
+	if muted {
+		volume = 0
+	}
+
+	script := fmt.Sprintf("set volume input volume %d", volume)
+	cmd := exec.Command("osascript", "-e", script)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error executing osascript to set state: %v\nOutput: %s", err, output)
+	}
+	log.Printf("Microphone set to Mute: %t. osascript output: %s", muted, strings.TrimSpace(string(output)))
+	return nil
+}
+
+func (m *darwinMicController) Muted() (bool, error) {
+	cmd := exec.Command("osascript", "-e", "get volume settings")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return false, fmt.Errorf("error executing osascript to get state: %v\nOutput: %s", err, output)
+	}
+
+	// The output looks like:
+	// "output volume:40, input volume:100, alert volume:100, output muted:false, input muted:false"
+	outputStr := strings.TrimSpace(string(output))
+	log.Printf("osascript output for get state: %s", outputStr)
+
+	idx := strings.Index(outputStr, "input muted:")
+	if idx == -1 {
+		return false, fmt.Errorf("cannot find 'input muted' in osascript output: %s", outputStr)
+	}
+
+	sub := outputStr[idx+len("input muted:"):]
+	end := strings.IndexAny(sub, ", \n")
+	if end == -1 {
+		end = len(sub)
+	}
+	return strings.TrimSpace(sub[:end]) == "true", nil
+}
+
+// SetProcessMuted would implement ScopedMicController by muting only
+// processName's input stream, but doing that on macOS requires tapping a
+// specific process with the Core Audio process tap API (introduced in
+// macOS 14.2), which needs cgo bindings against AudioToolbox that this
+// package doesn't have yet. osascript has no equivalent, so this always
+// fails rather than silently falling back to muting everything.
+func (m *darwinMicController) SetProcessMuted(processName string, muted bool) error {
+	return fmt.Errorf("per-application mute scope is not yet supported on macOS (requires a Core Audio process tap)")
+}
+
+// ProcessMuted is the read-side counterpart of SetProcessMuted; see there
+// for why it's unsupported.
+func (m *darwinMicController) ProcessMuted(processName string) (bool, error) {
+	return false, fmt.Errorf("per-application mute scope is not yet supported on macOS (requires a Core Audio process tap)")
+}
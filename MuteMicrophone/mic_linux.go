@@ -0,0 +1,83 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+)
+
+// linuxMicController drives PulseAudio/PipeWire (pipewire-pulse ships a
+// compatible pactl) through the pactl CLI. If targetDevice is set it is
+// resolved once, at construction time, to a concrete source name; otherwise
+// the default source is used.
+type linuxMicController struct {
+	source string
+}
+
+func newMicController(targetDevice string) (MicController, error) {
+	source := "@DEFAULT_SOURCE@"
+	if targetDevice != "" {
+		resolved, err := findPulseSource(targetDevice)
+		if err != nil {
+			return nil, err
+		}
+		source = resolved
+	}
+	return &linuxMicController{source: source}, nil
+}
+
+// findPulseSource resolves a case-insensitive substring match against
+// `pactl list short sources` to a concrete source name.
+func findPulseSource(substr string) (string, error) {
+	cmd := exec.Command("pactl", "list", "short", "sources")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("error executing 'pactl list short sources': %v\nOutput: %s", err, output)
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		name := fields[1]
+		if strings.Contains(strings.ToLower(name), strings.ToLower(substr)) {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no PulseAudio source matching %q found", substr)
+}
+
+func (m *linuxMicController) SetMuted(muted bool) error {
+	arg := "0"
+	if muted {
+		arg = "1"
+	}
+
+	cmd := exec.Command("pactl", "set-source-mute", m.source, arg)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error executing 'pactl set-source-mute': %v\nOutput: %s", err, output)
+	}
+	log.Printf("Source %s set to Mute: %t", m.source, muted)
+	return nil
+}
+
+func (m *linuxMicController) Muted() (bool, error) {
+	cmd := exec.Command("pactl", "get-source-mute", m.source)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return false, fmt.Errorf("error executing 'pactl get-source-mute': %v\nOutput: %s", err, output)
+	}
+
+	// Output looks like: "Mute: yes" or "Mute: no"
+	outputStr := strings.TrimSpace(string(output))
+	parts := strings.SplitN(outputStr, ":", 2)
+	if len(parts) != 2 {
+		return false, fmt.Errorf("unexpected 'pactl get-source-mute' output: %s", outputStr)
+	}
+	return strings.TrimSpace(parts[1]) == "yes", nil
+}
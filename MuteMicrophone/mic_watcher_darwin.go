@@ -0,0 +1,67 @@
+//go:build darwin
+
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// darwinMicWatcher polls "input muted" on an interval, since AppleScript has
+// no change-notification API for microphone mute state.
+type darwinMicWatcher struct {
+	changes chan bool
+	done    chan struct{}
+}
+
+// newMicWatcher starts polling mic every pollInterval for external mute
+// changes.
+func newMicWatcher(mic MicController, pollInterval time.Duration) (MicWatcher, error) {
+	w := &darwinMicWatcher{
+		changes: make(chan bool),
+		done:    make(chan struct{}),
+	}
+	go w.poll(mic, pollInterval)
+	return w, nil
+}
+
+func (w *darwinMicWatcher) poll(mic MicController, pollInterval time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	last, err := mic.Muted()
+	if err != nil {
+		log.Printf("Error polling initial microphone state: %v", err)
+	}
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			muted, err := mic.Muted()
+			if err != nil {
+				log.Printf("Error polling microphone state: %v", err)
+				continue
+			}
+			if muted == last {
+				continue
+			}
+			last = muted
+			select {
+			case w.changes <- muted:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+func (w *darwinMicWatcher) Changes() <-chan bool {
+	return w.changes
+}
+
+func (w *darwinMicWatcher) Close() error {
+	close(w.done)
+	return nil
+}
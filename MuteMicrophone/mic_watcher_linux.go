@@ -0,0 +1,86 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// linuxMicWatcher subscribes to PulseAudio/PipeWire's event stream via
+// `pactl subscribe` and re-queries the source's mute state whenever a source
+// change event arrives.
+type linuxMicWatcher struct {
+	cmd     *exec.Cmd
+	changes chan bool
+	done    chan struct{}
+}
+
+// newMicWatcher starts watching mic for external mute changes. pollInterval
+// is unused on Linux, which watches PulseAudio events instead of polling.
+func newMicWatcher(mic MicController, pollInterval time.Duration) (MicWatcher, error) {
+	cmd := exec.Command("pactl", "subscribe")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error opening 'pactl subscribe' stdout: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting 'pactl subscribe': %v", err)
+	}
+
+	w := &linuxMicWatcher{
+		cmd:     cmd,
+		changes: make(chan bool),
+		done:    make(chan struct{}),
+	}
+	go w.watch(mic, stdout)
+	return w, nil
+}
+
+func (w *linuxMicWatcher) watch(mic MicController, stdout io.Reader) {
+	last, err := mic.Muted()
+	if err != nil {
+		log.Printf("Error reading initial microphone state: %v", err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Lines look like: "Event 'change' on source #42"
+		if !strings.Contains(line, "on source") {
+			continue
+		}
+
+		muted, err := mic.Muted()
+		if err != nil {
+			log.Printf("Error reading microphone state after pactl event: %v", err)
+			continue
+		}
+		if muted == last {
+			continue
+		}
+		last = muted
+		select {
+		case w.changes <- muted:
+		case <-w.done:
+			return
+		}
+	}
+}
+
+func (w *linuxMicWatcher) Changes() <-chan bool {
+	return w.changes
+}
+
+func (w *linuxMicWatcher) Close() error {
+	close(w.done)
+	if w.cmd.Process != nil {
+		return w.cmd.Process.Kill()
+	}
+	return nil
+}
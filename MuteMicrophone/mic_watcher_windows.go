@@ -0,0 +1,71 @@
+//go:build windows
+
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// windowsMicWatcher polls IAudioEndpointVolume.GetMute on an interval.
+// go-wca's RegisterControlChangeNotify/UnregisterControlChangeNotify are
+// hard-coded to return E_NOTIMPL, so there's no WASAPI callback to register
+// here; polling is the only option until that's implemented upstream (or
+// vendored), same as the macOS fallback.
+type windowsMicWatcher struct {
+	changes chan bool
+	done    chan struct{}
+}
+
+// newMicWatcher starts polling mic every pollInterval for external mute
+// changes. mic must be the *windowsMicController returned by this
+// platform's newMicController.
+func newMicWatcher(mic MicController, pollInterval time.Duration) (MicWatcher, error) {
+	w := &windowsMicWatcher{
+		changes: make(chan bool),
+		done:    make(chan struct{}),
+	}
+	go w.poll(mic, pollInterval)
+	return w, nil
+}
+
+func (w *windowsMicWatcher) poll(mic MicController, pollInterval time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	last, err := mic.Muted()
+	if err != nil {
+		log.Printf("Error polling initial microphone state: %v", err)
+	}
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			muted, err := mic.Muted()
+			if err != nil {
+				log.Printf("Error polling microphone state: %v", err)
+				continue
+			}
+			if muted == last {
+				continue
+			}
+			last = muted
+			select {
+			case w.changes <- muted:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+func (w *windowsMicWatcher) Changes() <-chan bool {
+	return w.changes
+}
+
+func (w *windowsMicWatcher) Close() error {
+	close(w.done)
+	return nil
+}
@@ -0,0 +1,243 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/go-ole/go-ole"
+	"github.com/moutend/go-wca/pkg/wca"
+	"golang.org/x/sys/windows"
+)
+
+// windowsMicController controls the system microphone via WASAPI's
+// IAudioEndpointVolume on the chosen capture endpoint. device is kept alive
+// (rather than released once the endpoint volume is activated) so it can
+// later be used to enumerate per-application audio sessions for
+// SetProcessMuted/ProcessMuted.
+type windowsMicController struct {
+	device         *wca.IMMDevice
+	endpointVolume *wca.IAudioEndpointVolume
+}
+
+// newMicController picks a capture endpoint and activates its
+// IAudioEndpointVolume. If targetDevice is empty the default capture
+// endpoint is used; otherwise the first active capture endpoint whose
+// friendly name contains targetDevice (case-insensitive) is used.
+func newMicController(targetDevice string) (MicController, error) {
+	if err := ole.CoInitializeEx(0, ole.COINIT_APARTMENTTHREADED); err != nil {
+		return nil, fmt.Errorf("error initializing COM: %v", err)
+	}
+
+	var enumerator *wca.IMMDeviceEnumerator
+	if err := wca.CoCreateInstance(wca.CLSID_MMDeviceEnumerator, 0, wca.CLSCTX_ALL, wca.IID_IMMDeviceEnumerator, &enumerator); err != nil {
+		return nil, fmt.Errorf("error creating device enumerator: %v", err)
+	}
+	defer enumerator.Release()
+
+	device, err := selectCaptureDevice(enumerator, targetDevice)
+	if err != nil {
+		return nil, err
+	}
+
+	var endpointVolume *wca.IAudioEndpointVolume
+	if err := device.Activate(wca.IID_IAudioEndpointVolume, wca.CLSCTX_ALL, nil, &endpointVolume); err != nil {
+		device.Release()
+		return nil, fmt.Errorf("error activating IAudioEndpointVolume: %v", err)
+	}
+
+	return &windowsMicController{device: device, endpointVolume: endpointVolume}, nil
+}
+
+// selectCaptureDevice returns the default capture endpoint, or the first
+// active capture endpoint whose friendly name contains targetDevice.
+func selectCaptureDevice(enumerator *wca.IMMDeviceEnumerator, targetDevice string) (*wca.IMMDevice, error) {
+	if targetDevice == "" {
+		var device *wca.IMMDevice
+		if err := enumerator.GetDefaultAudioEndpoint(wca.ECapture, wca.EConsole, &device); err != nil {
+			return nil, fmt.Errorf("error getting default capture endpoint: %v", err)
+		}
+		return device, nil
+	}
+
+	var collection *wca.IMMDeviceCollection
+	if err := enumerator.EnumAudioEndpoints(wca.ECapture, wca.DEVICE_STATE_ACTIVE, &collection); err != nil {
+		return nil, fmt.Errorf("error enumerating capture endpoints: %v", err)
+	}
+	defer collection.Release()
+
+	var count uint32
+	if err := collection.GetCount(&count); err != nil {
+		return nil, fmt.Errorf("error counting capture endpoints: %v", err)
+	}
+
+	for i := uint32(0); i < count; i++ {
+		var device *wca.IMMDevice
+		if err := collection.Item(i, &device); err != nil {
+			continue
+		}
+
+		name, err := endpointFriendlyName(device)
+		if err == nil && strings.Contains(strings.ToLower(name), strings.ToLower(targetDevice)) {
+			return device, nil
+		}
+		device.Release()
+	}
+
+	return nil, fmt.Errorf("no capture endpoint matching %q found", targetDevice)
+}
+
+// endpointFriendlyName reads the PKEY_Device_FriendlyName property of device.
+func endpointFriendlyName(device *wca.IMMDevice) (string, error) {
+	var store *wca.IPropertyStore
+	if err := device.OpenPropertyStore(wca.STGM_READ, &store); err != nil {
+		return "", err
+	}
+	defer store.Release()
+
+	var value wca.PROPVARIANT
+	if err := store.GetValue(&wca.PKEY_Device_FriendlyName, &value); err != nil {
+		return "", err
+	}
+	return value.String(), nil
+}
+
+func (m *windowsMicController) SetMuted(muted bool) error {
+	if err := m.endpointVolume.SetMute(muted, nil); err != nil {
+		return fmt.Errorf("error setting mute via IAudioEndpointVolume: %v", err)
+	}
+	log.Printf("Capture endpoint set to Mute: %t", muted)
+	return nil
+}
+
+func (m *windowsMicController) Muted() (bool, error) {
+	var muted bool
+	if err := m.endpointVolume.GetMute(&muted); err != nil {
+		return false, fmt.Errorf("error getting mute via IAudioEndpointVolume: %v", err)
+	}
+	return muted, nil
+}
+
+// SetProcessMuted implements ScopedMicController by muting only the audio
+// session belonging to processName (matched case-insensitively against the
+// session's executable name, e.g. "zoom.exe"), rather than the whole
+// capture endpoint.
+func (m *windowsMicController) SetProcessMuted(processName string, muted bool) error {
+	volume, err := m.findSessionSimpleVolume(processName)
+	if err != nil {
+		return err
+	}
+	defer volume.Release()
+
+	if err := volume.SetMute(muted, nil); err != nil {
+		return fmt.Errorf("error setting mute on audio session for %q: %v", processName, err)
+	}
+	log.Printf("Audio session for %q set to Mute: %t", processName, muted)
+	return nil
+}
+
+// ProcessMuted implements ScopedMicController.
+func (m *windowsMicController) ProcessMuted(processName string) (bool, error) {
+	volume, err := m.findSessionSimpleVolume(processName)
+	if err != nil {
+		return false, err
+	}
+	defer volume.Release()
+
+	var muted bool
+	if err := volume.GetMute(&muted); err != nil {
+		return false, fmt.Errorf("error getting mute on audio session for %q: %v", processName, err)
+	}
+	return muted, nil
+}
+
+// findSessionSimpleVolume enumerates the capture device's audio sessions
+// and returns the ISimpleAudioVolume of the first one whose owning
+// process's executable name contains processName (case-insensitive).
+func (m *windowsMicController) findSessionSimpleVolume(processName string) (*wca.ISimpleAudioVolume, error) {
+	var sessionManager *wca.IAudioSessionManager2
+	if err := m.device.Activate(wca.IID_IAudioSessionManager2, wca.CLSCTX_ALL, nil, &sessionManager); err != nil {
+		return nil, fmt.Errorf("error activating IAudioSessionManager2: %v", err)
+	}
+	defer sessionManager.Release()
+
+	var sessionEnumerator *wca.IAudioSessionEnumerator
+	if err := sessionManager.GetSessionEnumerator(&sessionEnumerator); err != nil {
+		return nil, fmt.Errorf("error getting audio session enumerator: %v", err)
+	}
+	defer sessionEnumerator.Release()
+
+	var count int
+	if err := sessionEnumerator.GetCount(&count); err != nil {
+		return nil, fmt.Errorf("error counting audio sessions: %v", err)
+	}
+
+	for i := 0; i < count; i++ {
+		var sessionControl *wca.IAudioSessionControl
+		if err := sessionEnumerator.GetSession(i, &sessionControl); err != nil {
+			continue
+		}
+
+		name, volume, err := processSessionVolume(sessionControl)
+		sessionControl.Release()
+		if err != nil {
+			continue
+		}
+		if strings.Contains(strings.ToLower(name), strings.ToLower(processName)) {
+			return volume, nil
+		}
+		volume.Release()
+	}
+
+	return nil, fmt.Errorf("no audio session matching process %q found", processName)
+}
+
+// processSessionVolume resolves the executable name and ISimpleAudioVolume
+// for one audio session.
+func processSessionVolume(sessionControl *wca.IAudioSessionControl) (string, *wca.ISimpleAudioVolume, error) {
+	var sessionControl2 *wca.IAudioSessionControl2
+	if err := sessionControl.PutQueryInterface(wca.IID_IAudioSessionControl2, &sessionControl2); err != nil {
+		return "", nil, fmt.Errorf("error querying IAudioSessionControl2: %v", err)
+	}
+	defer sessionControl2.Release()
+
+	var pid uint32
+	if err := sessionControl2.GetProcessId(&pid); err != nil {
+		return "", nil, fmt.Errorf("error getting session process id: %v", err)
+	}
+
+	name, err := processExecutableName(pid)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var volume *wca.ISimpleAudioVolume
+	if err := sessionControl.PutQueryInterface(wca.IID_ISimpleAudioVolume, &volume); err != nil {
+		return "", nil, fmt.Errorf("error querying ISimpleAudioVolume: %v", err)
+	}
+	return name, volume, nil
+}
+
+// processExecutableName returns the base executable name (e.g. "zoom.exe")
+// of the process identified by pid.
+func processExecutableName(pid uint32) (string, error) {
+	handle, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return "", fmt.Errorf("error opening process %d: %v", pid, err)
+	}
+	defer windows.CloseHandle(handle)
+
+	buf := make([]uint16, windows.MAX_PATH)
+	size := uint32(len(buf))
+	if err := windows.QueryFullProcessImageName(handle, 0, &buf[0], &size); err != nil {
+		return "", fmt.Errorf("error querying image name for process %d: %v", pid, err)
+	}
+
+	path := windows.UTF16ToString(buf[:size])
+	if idx := strings.LastIndexAny(path, `\/`); idx != -1 {
+		path = path[idx+1:]
+	}
+	return path, nil
+}
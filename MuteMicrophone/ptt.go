@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// ScopedMicController is implemented by platforms that can mute a single
+// application's capture session instead of the whole capture device. Used
+// when PTTConfig.ScopeProcess is set.
+type ScopedMicController interface {
+	SetProcessMuted(processName string, muted bool) error
+	ProcessMuted(processName string) (bool, error)
+}
+
+// pttController turns a device's PRESS/RELEASE edge events into mic
+// mute/unmute calls according to the configured PTTConfig.Mode, tracking the
+// in-flight press so Release can decide what to do based on how long the
+// button was held and what the mic state was beforehand.
+type pttController struct {
+	mic          MicController
+	echo         *echoSuppressor
+	mode         string
+	holdThresh   time.Duration
+	scopeProcess string
+
+	pressTime time.Time
+	preMuted  bool
+}
+
+// newPTTController builds a pttController from cfg.PTT.
+func newPTTController(mic MicController, echo *echoSuppressor, cfg Config) *pttController {
+	return &pttController{
+		mic:          mic,
+		echo:         echo,
+		mode:         cfg.PTT.PTTMode(),
+		holdThresh:   cfg.PTT.HoldThreshold(),
+		scopeProcess: cfg.PTT.ScopeProcess,
+	}
+}
+
+// Press handles a PRESS edge from the device.
+func (p *pttController) Press(label string) {
+	muted, err := p.muted()
+	if err != nil {
+		log.Printf("[%s] PTT: error reading microphone state on press: %v", label, err)
+		return
+	}
+	p.pressTime = time.Now()
+	p.preMuted = muted
+
+	switch p.mode {
+	case pttModeCough:
+		if !muted {
+			if err := p.setMuted(true); err != nil {
+				log.Printf("[%s] PTT: error muting microphone for cough: %v", label, err)
+			}
+		}
+	case pttModeMomentary, pttModeAuto:
+		if err := p.setMuted(false); err != nil {
+			log.Printf("[%s] PTT: error unmuting microphone on press: %v", label, err)
+		}
+	case pttModeToggle:
+		// Toggle only acts on release.
+	}
+}
+
+// Release handles a RELEASE edge from the device.
+func (p *pttController) Release(label string) {
+	switch p.mode {
+	case pttModeCough:
+		if !p.preMuted {
+			if err := p.setMuted(false); err != nil {
+				log.Printf("[%s] PTT: error unmuting microphone after cough: %v", label, err)
+			}
+		}
+
+	case pttModeMomentary:
+		if err := p.setMuted(true); err != nil {
+			log.Printf("[%s] PTT: error muting microphone on release: %v", label, err)
+		}
+
+	case pttModeToggle:
+		if err := p.setMuted(!p.preMuted); err != nil {
+			log.Printf("[%s] PTT: error toggling microphone on release: %v", label, err)
+		}
+
+	case pttModeAuto:
+		if held := time.Since(p.pressTime); held >= p.holdThresh {
+			// Held long enough to be momentary: mute back on release.
+			if err := p.setMuted(true); err != nil {
+				log.Printf("[%s] PTT: error muting microphone after momentary hold: %v", label, err)
+			}
+		} else {
+			// Short tap: behave like a toggle click.
+			if err := p.setMuted(!p.preMuted); err != nil {
+				log.Printf("[%s] PTT: error toggling microphone after short press: %v", label, err)
+			}
+		}
+	}
+}
+
+// muted reads the current mute state, scoped to scopeProcess if configured.
+func (p *pttController) muted() (bool, error) {
+	if p.scopeProcess == "" {
+		return p.mic.Muted()
+	}
+	scoped, ok := p.mic.(ScopedMicController)
+	if !ok {
+		return false, fmt.Errorf("microphone controller does not support per-application scope on this platform")
+	}
+	return scoped.ProcessMuted(p.scopeProcess)
+}
+
+// setMuted sets the mute state, scoped to scopeProcess if configured.
+// Scoped changes bypass the echoSuppressor: it exists to debounce the
+// global-device watcher's polling loop, which never observes per-process
+// session state.
+func (p *pttController) setMuted(muted bool) error {
+	if p.scopeProcess == "" {
+		return setMicMuted(p.mic, p.echo, muted)
+	}
+	scoped, ok := p.mic.(ScopedMicController)
+	if !ok {
+		return fmt.Errorf("microphone controller does not support per-application scope on this platform")
+	}
+	return scoped.SetProcessMuted(p.scopeProcess, muted)
+}
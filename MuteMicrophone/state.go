@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// echoTimeout bounds how long a self-initiated mute change is remembered
+// while waiting for the corresponding MicWatcher notification. If the OS
+// never reports it back (or coalesces it with something else), the
+// suppressor forgets it rather than permanently refusing to report that
+// state again.
+const echoTimeout = 2 * time.Second
+
+// echoSuppressor tracks mute-state changes we made ourselves so that the
+// matching MicWatcher notification can be recognized as an echo of our own
+// write and swallowed, instead of being re-pushed to the serial port as if
+// a human had flipped the OS mute control. Without this, every MUTE/UNMUTE
+// command from the Arduino would bounce right back to it as an unsolicited
+// state push.
+type echoSuppressor struct {
+	mu      sync.Mutex
+	pending *bool
+	timer   *time.Timer
+}
+
+// expect records that we are about to set the microphone to muted ourselves.
+func (s *echoSuppressor) expect(muted bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending = &muted
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.timer = time.AfterFunc(echoTimeout, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.pending = nil
+	})
+}
+
+// isEcho reports whether muted matches a state we just set ourselves and,
+// if so, consumes it so that the next unrelated change is reported normally.
+func (s *echoSuppressor) isEcho(muted bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pending == nil || *s.pending != muted {
+		return false
+	}
+	s.pending = nil
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	return true
+}
+
+// setMicMuted sets mic's mute state and tells echo to expect it, so the
+// watcher notification it provokes (if any) is recognized as our own echo.
+func setMicMuted(mic MicController, echo *echoSuppressor, muted bool) error {
+	echo.expect(muted)
+	return mic.SetMuted(muted)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Supervisor watches the system's USB serial ports for devices matching the
+// configured {vid, pid, role} entries, running one handler goroutine per
+// connected device and tearing it down cleanly when the device disappears.
+type Supervisor struct {
+	mic MicController
+	cfg Config
+
+	devices []DeviceConfig
+	running map[string]*runningDevice // keyed by port address
+}
+
+// runningDevice tracks a handler goroutine the Supervisor has started for a
+// currently-connected device.
+type runningDevice struct {
+	role   string
+	cancel func()
+	done   chan struct{}
+}
+
+// newSupervisor builds a Supervisor for cfg.Devices, defaulting to a single
+// roleMaster device using targetArduinoVID/targetArduinoPID when none are
+// configured, matching the original single-board behavior.
+func newSupervisor(mic MicController, cfg Config) *Supervisor {
+	devices := cfg.Devices
+	if len(devices) == 0 {
+		devices = []DeviceConfig{{VID: targetArduinoVID, PID: targetArduinoPID, Role: roleMaster}}
+	}
+	return &Supervisor{
+		mic:     mic,
+		cfg:     cfg,
+		devices: devices,
+		running: make(map[string]*runningDevice),
+	}
+}
+
+// Run polls for matching ports, starting and stopping device handlers as
+// they come and go. It never returns under normal operation.
+func (sv *Supervisor) Run() {
+	interval := sv.cfg.DiscoveryPollInterval()
+	for {
+		ports, err := discoverPorts()
+		if err != nil {
+			log.Printf("[supervisor] Error discovering ports: %v", err)
+		} else {
+			sv.reconcile(ports)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// reconcile starts handlers for newly matching ports and tears down
+// handlers for ports that are no longer present.
+func (sv *Supervisor) reconcile(ports []discoveredPort) {
+	seen := make(map[string]bool, len(ports))
+
+	for _, port := range ports {
+		dc, ok := sv.matchDevice(port)
+		if !ok {
+			continue
+		}
+		seen[port.Address] = true
+
+		if rd, exists := sv.running[port.Address]; exists {
+			select {
+			case <-rd.done:
+				// The handler already exited on its own (e.g. the serial
+				// port failed to open, or the handshake timed out) while
+				// the port is still physically present. Forget it so the
+				// loop below starts a fresh attempt instead of treating
+				// the device as permanently handled.
+				delete(sv.running, port.Address)
+			default:
+				continue
+			}
+		}
+		sv.start(port.Address, dc)
+	}
+
+	for address, rd := range sv.running {
+		if seen[address] {
+			continue
+		}
+		log.Printf("[supervisor] Device %s (role=%s) disappeared", address, rd.role)
+		rd.cancel()
+		<-rd.done
+		delete(sv.running, address)
+
+		if rd.role == roleMaster {
+			log.Printf("[supervisor] Master device disconnected; unmuting microphone for safety")
+			// No echoSuppressor to notify here: the master's own session (and
+			// its watcher) has already torn down, and any other device's
+			// session should see this as a real state change to push, not an
+			// echo of its own doing.
+			if err := sv.mic.SetMuted(false); err != nil {
+				log.Printf("[supervisor] Error unmuting microphone after master disconnect: %v", err)
+			}
+		}
+	}
+}
+
+// matchDevice returns the first configured device entry whose VID/PID
+// matches port.
+func (sv *Supervisor) matchDevice(port discoveredPort) (DeviceConfig, bool) {
+	for _, dc := range sv.devices {
+		if normalizeHexID(dc.VID) == port.VID && normalizeHexID(dc.PID) == port.PID {
+			return dc, true
+		}
+	}
+	return DeviceConfig{}, false
+}
+
+// start launches the handler goroutine for a newly discovered device.
+func (sv *Supervisor) start(address string, dc DeviceConfig) {
+	label := fmt.Sprintf("%s(%s)", address, dc.Role)
+	log.Printf("[supervisor] Device %s connected", label)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	sv.running[address] = &runningDevice{
+		role:   dc.Role,
+		cancel: func() { close(stop) },
+		done:   done,
+	}
+
+	go func() {
+		defer close(done)
+		runDeviceAtAddress(label, address, stop, sv.mic, sv.cfg)
+	}()
+}
+
+// runDeviceAtAddress opens the serial port at address and runs the device
+// protocol over it once, returning when the connection drops or stop is
+// closed.
+func runDeviceAtAddress(label, address string, stop <-chan struct{}, mic MicController, cfg Config) {
+	transport, err := openSerialTransportAt(address)
+	if err != nil {
+		log.Printf("[%s] Error opening serial port: %v", label, err)
+		return
+	}
+
+	sessionDone := make(chan struct{})
+	go func() {
+		defer close(sessionDone)
+		runDeviceSession(label, transport, mic, cfg)
+	}()
+
+	select {
+	case <-sessionDone:
+	case <-stop:
+		transport.Close()
+		<-sessionDone
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// Transport abstracts a duplex, newline-delimited byte stream to a device,
+// whether that's a serial port, a TCP socket, or a WebSocket connection.
+// The handshake and protocol codec operate purely in terms of this
+// interface, so they don't need to know which kind of link they're running
+// over.
+type Transport interface {
+	io.Reader
+	io.Writer
+	io.Closer
+	// Name identifies the transport for logging, e.g. "/dev/ttyACM0" or
+	// "tcp://192.168.1.50:9000".
+	Name() string
+}
+
+// newTransportOpener returns a function that acquires one Transport
+// instance of the kind described by tc. For listening transports (TCP or
+// WebSocket "listen" mode), calling the returned function blocks until a
+// device connects.
+func newTransportOpener(tc TransportConfig) (func() (Transport, error), error) {
+	switch tc.Type {
+	case transportTypeTCPDial:
+		if tc.Address == "" {
+			return nil, fmt.Errorf("tcp-dial transport requires an address")
+		}
+		return func() (Transport, error) { return DialTCP(tc.Address) }, nil
+
+	case transportTypeTCPListen:
+		if tc.Address == "" {
+			return nil, fmt.Errorf("tcp-listen transport requires an address")
+		}
+		return func() (Transport, error) { return ListenTCP(tc.Address) }, nil
+
+	case transportTypeWSListen:
+		if tc.Address == "" {
+			return nil, fmt.Errorf("ws-listen transport requires an address")
+		}
+		path := tc.Path
+		if path == "" {
+			path = "/"
+		}
+		return func() (Transport, error) { return ListenWebSocket(tc.Address, path) }, nil
+
+	default:
+		return nil, fmt.Errorf("unknown transport type %q (USB serial devices are configured via \"devices\", not \"transports\")", tc.Type)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+
+	"go.bug.st/serial"
+)
+
+// SerialTransport is a Transport over a USB serial connection to a device.
+type SerialTransport struct {
+	serial.Port
+	name string
+}
+
+// Name implements Transport.
+func (t *SerialTransport) Name() string {
+	return t.name
+}
+
+// openSerialTransportAt opens a serial connection to the device already
+// known to be at address, as reported by discoverPorts.
+func openSerialTransportAt(address string) (Transport, error) {
+	mode := &serial.Mode{
+		BaudRate: serialBaud,
+		Parity:   serial.NoParity,
+		DataBits: 8,
+		StopBits: serial.OneStopBit,
+	}
+
+	port, err := serial.Open(address, mode)
+	if err != nil {
+		return nil, fmt.Errorf("error opening serial port %s: %v", address, err)
+	}
+
+	return &SerialTransport{Port: port, name: address}, nil
+}
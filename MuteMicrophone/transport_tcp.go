@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+)
+
+// TCPTransport is a Transport over a plain TCP connection, used by
+// networked devices (e.g. an ESP32/ESP8266 button box) that would otherwise
+// need a USB/serial link.
+type TCPTransport struct {
+	conn net.Conn
+	name string
+}
+
+// DialTCP connects to a device listening at addr (host:port).
+func DialTCP(addr string) (Transport, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("error dialing TCP device at %s: %v", addr, err)
+	}
+	return &TCPTransport{conn: conn, name: fmt.Sprintf("tcp://%s", addr)}, nil
+}
+
+// ListenTCP listens on addr and accepts a single device connection. It
+// blocks until a device connects or the listener fails.
+func ListenTCP(addr string) (Transport, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("error listening for TCP device on %s: %v", addr, err)
+	}
+	defer ln.Close()
+
+	log.Printf("Waiting for a TCP device to connect on %s...", addr)
+	conn, err := ln.Accept()
+	if err != nil {
+		return nil, fmt.Errorf("error accepting TCP device connection on %s: %v", addr, err)
+	}
+	return &TCPTransport{conn: conn, name: fmt.Sprintf("tcp://%s", conn.RemoteAddr())}, nil
+}
+
+func (t *TCPTransport) Read(p []byte) (int, error)  { return t.conn.Read(p) }
+func (t *TCPTransport) Write(p []byte) (int, error) { return t.conn.Write(p) }
+func (t *TCPTransport) Close() error                { return t.conn.Close() }
+func (t *TCPTransport) Name() string                { return t.name }
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsUpgrader uses gorilla's defaults: requests with no Origin header (as
+// a microcontroller client sends them) are accepted, and requests whose
+// Origin host differs from the request Host are rejected.
+var wsUpgrader = websocket.Upgrader{}
+
+// WebSocketTransport is a Transport over a WebSocket connection, framing
+// each Write as one WebSocket text message and reassembling Read calls from
+// whatever message arrives next.
+type WebSocketTransport struct {
+	conn *websocket.Conn
+	name string
+
+	mu  sync.Mutex
+	buf []byte // leftover bytes from a message that didn't fit the caller's slice
+}
+
+// ListenWebSocket starts an HTTP server on addr and accepts a single
+// WebSocket device connection at path. It blocks until a device connects or
+// the listener fails.
+func ListenWebSocket(addr, path string) (Transport, error) {
+	connChan := make(chan *websocket.Conn, 1)
+	errChan := make(chan error, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		conn, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			errChan <- fmt.Errorf("error upgrading WebSocket connection: %v", err)
+			return
+		}
+		connChan <- conn
+	})
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errChan <- fmt.Errorf("error serving WebSocket listener: %v", err)
+		}
+	}()
+
+	log.Printf("Waiting for a WebSocket device to connect on ws://%s%s...", addr, path)
+	select {
+	case conn := <-connChan:
+		go server.Close() // stop accepting further upgrades; this is a single-device listener
+		return &WebSocketTransport{conn: conn, name: fmt.Sprintf("ws://%s%s", addr, path)}, nil
+	case err := <-errChan:
+		server.Close()
+		return nil, err
+	}
+}
+
+// Write sends p as a single WebSocket text message.
+func (t *WebSocketTransport) Write(p []byte) (int, error) {
+	if err := t.conn.WriteMessage(websocket.TextMessage, p); err != nil {
+		return 0, fmt.Errorf("error writing WebSocket message: %v", err)
+	}
+	return len(p), nil
+}
+
+// Read copies from the next WebSocket message into p, buffering any bytes
+// that don't fit so the next Read call picks up where this one left off.
+func (t *WebSocketTransport) Read(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for len(t.buf) == 0 {
+		_, data, err := t.conn.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+		t.buf = data
+	}
+
+	n := copy(p, t.buf)
+	t.buf = t.buf[n:]
+	return n, nil
+}
+
+func (t *WebSocketTransport) Close() error { return t.conn.Close() }
+func (t *WebSocketTransport) Name() string { return t.name }
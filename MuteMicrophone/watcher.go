@@ -0,0 +1,15 @@
+package main
+
+// MicWatcher reports microphone mute-state changes that happen outside this
+// process (e.g. via the OS volume UI), so the connected microcontroller's
+// LED can be kept in sync without waiting for its next GET_STATE poll. Each
+// OS provides its own implementation in a mic_watcher_<os>.go file,
+// constructed through newMicWatcher.
+type MicWatcher interface {
+	// Changes streams the microphone's muted state each time the OS reports
+	// it changing. The channel is not closed by Close; callers should stop
+	// reading from it once Close returns.
+	Changes() <-chan bool
+	// Close stops the watcher and releases any OS resources it holds.
+	Close() error
+}
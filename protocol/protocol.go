@@ -0,0 +1,160 @@
+// Package protocol defines the newline-delimited JSON wire format exchanged
+// between the Go daemon and a microcontroller sketch once the HELLO
+// handshake has negotiated JSON mode, and the codec used to read and write
+// it.
+package protocol
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// HandshakeName identifies this daemon in the HELLO line it sends, e.g.
+// `HELLO 1 "microphone-remote"`.
+const HandshakeName = "microphone-remote"
+
+// HandshakeVersion is the version of the HELLO request line itself. It is
+// independent of Hello.ProtocolVersion, which is the version the device
+// reports supporting in its reply.
+const HandshakeVersion = 1
+
+// MinStatePushVersion is the minimum negotiated protocol version at which
+// unsolicited "state" events may be pushed to the device.
+const MinStatePushVersion = 2
+
+// EventType values used in the handshake reply.
+const (
+	EventTypeHello = "hello"
+)
+
+// Event names used in post-handshake JSON messages.
+const (
+	EventState = "state"
+	EventError = "error"
+)
+
+// Cmd values a device sends us.
+const (
+	CmdSet     = "set"
+	CmdGet     = "get"
+	CmdPress   = "press"   // push-to-talk button went down
+	CmdRelease = "release" // push-to-talk button came back up
+)
+
+// Error codes used in Event.Code.
+const (
+	ErrorCodeMuteFailed   = "mute_failed"
+	ErrorCodeUnmuteFailed = "unmute_failed"
+	ErrorCodeStateFailed  = "state_failed"
+	ErrorCodeUnknownCmd   = "unknown_cmd"
+)
+
+// Capability names a device can declare in its Hello.Capabilities.
+const (
+	// CapabilityStatePush means the device accepts unsolicited "state"
+	// events, not just replies to a "get" command. Requires
+	// ProtocolVersion >= MinStatePushVersion.
+	CapabilityStatePush = "state-push"
+
+	// CapabilityPTT means the device sends CmdPress/CmdRelease edge events
+	// for a push-to-talk button instead of CmdSet level commands.
+	CapabilityPTT = "ptt"
+)
+
+// Hello is the handshake reply a device sends after receiving our
+// `HELLO <HandshakeVersion> "<HandshakeName>"` line.
+type Hello struct {
+	EventType       string   `json:"eventType"`
+	ProtocolVersion int      `json:"protocolVersion"`
+	Firmware        string   `json:"firmware"`
+	Capabilities    []string `json:"capabilities"`
+}
+
+// SupportsStatePush reports whether h's device accepts unsolicited state
+// events.
+func (h Hello) SupportsStatePush() bool {
+	if h.ProtocolVersion < MinStatePushVersion {
+		return false
+	}
+	for _, c := range h.Capabilities {
+		if c == CapabilityStatePush {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsPTT reports whether h's device sends push-to-talk press/release
+// edge events rather than level commands.
+func (h Hello) SupportsPTT() bool {
+	for _, c := range h.Capabilities {
+		if c == CapabilityPTT {
+			return true
+		}
+	}
+	return false
+}
+
+// Command is a request sent from the device to the daemon, e.g.
+// {"cmd":"set","muted":true} or {"cmd":"get"}.
+type Command struct {
+	Cmd   string `json:"cmd"`
+	Muted bool   `json:"muted,omitempty"`
+}
+
+// Event is a notification sent from the daemon to the device, either as a
+// reply to a Command or (when the handshake allows it) unsolicited.
+type Event struct {
+	Event string `json:"event"`
+	Muted bool   `json:"muted,omitempty"`
+	Code  string `json:"code,omitempty"`
+}
+
+// Encoder writes newline-delimited JSON messages to an underlying writer.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns an Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode marshals v to JSON and writes it to the underlying writer followed
+// by a newline.
+func (e *Encoder) Encode(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("protocol: error marshaling message: %v", err)
+	}
+	data = append(data, '\n')
+	if _, err := e.w.Write(data); err != nil {
+		return fmt.Errorf("protocol: error writing message: %v", err)
+	}
+	return nil
+}
+
+// Decoder reads newline-delimited JSON messages from an underlying reader.
+type Decoder struct {
+	r *bufio.Reader
+}
+
+// NewDecoder returns a Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next newline-delimited line and unmarshals it into v.
+func (d *Decoder) Decode(v interface{}) error {
+	line, err := d.r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(line)), v); err != nil {
+		return fmt.Errorf("protocol: error unmarshaling message %q: %v", strings.TrimSpace(line), err)
+	}
+	return nil
+}
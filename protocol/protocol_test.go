@@ -0,0 +1,105 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+
+	if err := enc.Encode(Command{Cmd: CmdSet, Muted: true}); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	dec := NewDecoder(&buf)
+	var got Command
+	if err := dec.Decode(&got); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	want := Command{Cmd: CmdSet, Muted: true}
+	if got != want {
+		t.Errorf("Decode() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDecoderReadsOneMessagePerLine(t *testing.T) {
+	buf := bytes.NewBufferString("{\"cmd\":\"get\"}\n{\"cmd\":\"set\",\"muted\":true}\n")
+	dec := NewDecoder(buf)
+
+	var first, second Command
+	if err := dec.Decode(&first); err != nil {
+		t.Fatalf("Decode() first error = %v", err)
+	}
+	if err := dec.Decode(&second); err != nil {
+		t.Fatalf("Decode() second error = %v", err)
+	}
+
+	if first != (Command{Cmd: CmdGet}) {
+		t.Errorf("first = %+v, want {Cmd: %q}", first, CmdGet)
+	}
+	if second != (Command{Cmd: CmdSet, Muted: true}) {
+		t.Errorf("second = %+v, want {Cmd: %q, Muted: true}", second, CmdSet)
+	}
+}
+
+func TestHelloSupportsStatePush(t *testing.T) {
+	tests := []struct {
+		name string
+		h    Hello
+		want bool
+	}{
+		{
+			name: "version too low",
+			h:    Hello{ProtocolVersion: 1, Capabilities: []string{CapabilityStatePush}},
+			want: false,
+		},
+		{
+			name: "missing capability",
+			h:    Hello{ProtocolVersion: 2, Capabilities: []string{"something-else"}},
+			want: false,
+		},
+		{
+			name: "version and capability present",
+			h:    Hello{ProtocolVersion: 2, Capabilities: []string{CapabilityStatePush}},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.h.SupportsStatePush(); got != tt.want {
+				t.Errorf("SupportsStatePush() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHelloSupportsPTT(t *testing.T) {
+	tests := []struct {
+		name string
+		h    Hello
+		want bool
+	}{
+		{
+			name: "missing capability",
+			h:    Hello{ProtocolVersion: 1, Capabilities: []string{"something-else"}},
+			want: false,
+		},
+		{
+			name: "capability present",
+			h:    Hello{ProtocolVersion: 1, Capabilities: []string{CapabilityPTT}},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.h.SupportsPTT(); got != tt.want {
+				t.Errorf("SupportsPTT() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}